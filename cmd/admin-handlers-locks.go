@@ -0,0 +1,51 @@
+/*
+ * MinIO Cloud Storage, (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/minio/minio/cmd/logger"
+)
+
+// LocksGraphHandler - GET /minio/admin/v3/locks/graph
+//
+// Returns every resource that currently has at least one lock holder or
+// waiter, along with the opsID, lock source and acquisition/wait start
+// time for each side. Meant to be registered alongside the rest of the
+// /minio/admin/v3/locks/* routes.
+func LocksGraphHandler(w http.ResponseWriter, r *http.Request) {
+	writeLockGraphJSON(w, globalLockGraph.Graph())
+}
+
+// LocksDeadlocksHandler - GET /minio/admin/v3/locks/deadlocks
+//
+// Returns every wait-for cycle currently present in the lock graph, each
+// expressed as the ordered sequence of opsIDs involved. An empty array
+// means no deadlock is currently detected.
+func LocksDeadlocksHandler(w http.ResponseWriter, r *http.Request) {
+	writeLockGraphJSON(w, globalLockGraph.Deadlocks())
+}
+
+func writeLockGraphJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.LogIf(GlobalContext, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,273 @@
+/*
+ * MinIO Cloud Storage, (C) 2017-2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// dynamicTimeoutLogSize is the number of log entries (successes and
+	// failures combined, across all shards) between two recomputations of
+	// the percentiles and the timeout value.
+	dynamicTimeoutLogSize = 16
+
+	// dynamicTimeoutShards splits the latency reservoir into independent
+	// shards so that LogSuccess/LogFailure never contend on a single
+	// global mutex on the hot path. Each caller only ever touches the
+	// shard it is routed to.
+	dynamicTimeoutShards = 8
+
+	// dynamicTimeoutShardSize is the number of most recent success
+	// latencies retained per shard. Combined across all shards this
+	// gives a reservoir of dynamicTimeoutShards*dynamicTimeoutShardSize
+	// samples (1024 by default), comfortably inside the 256-1024 range.
+	dynamicTimeoutShardSize = 128
+
+	// dynamicTimeoutFailureMultiplier is applied to the current timeout
+	// every time a failure (i.e. a timed out operation) is logged.
+	dynamicTimeoutFailureMultiplier = 1.5
+
+	// dynamicTimeoutPercentileMultiplier (k) is the factor applied to the
+	// observed p99 latency to arrive at a new candidate timeout.
+	dynamicTimeoutPercentileMultiplier = 2
+
+	// dynamicTimeoutDecreaseDamping controls how aggressively the timeout
+	// is allowed to fall towards the p99-derived candidate on a single
+	// recomputation - a gentle decrease instead of a hard jump.
+	dynamicTimeoutDecreaseDamping = 4
+
+	// dynamicTimeoutMaxTimeout is a safety ceiling - no DynamicTimeout
+	// should ever grow past this value, no matter how many failures are
+	// logged in a row.
+	dynamicTimeoutMaxTimeout = 24 * time.Hour
+)
+
+// timeoutShard holds a bounded ring buffer of recent success latencies plus
+// failure/total counters for a single shard of a DynamicTimeout. All fields
+// are guarded by mu, but since each shard is only a fraction of the overall
+// traffic, contention on this lock stays low even though the lock itself is
+// not sharded any further.
+type timeoutShard struct {
+	mu       sync.Mutex
+	samples  [dynamicTimeoutShardSize]time.Duration
+	filled   int
+	next     int
+	failures uint64
+	total    uint64
+}
+
+func (s *timeoutShard) logSuccess(d time.Duration) {
+	s.mu.Lock()
+	s.samples[s.next] = d
+	s.next = (s.next + 1) % dynamicTimeoutShardSize
+	if s.filled < dynamicTimeoutShardSize {
+		s.filled++
+	}
+	s.total++
+	s.mu.Unlock()
+}
+
+func (s *timeoutShard) logFailure() {
+	s.mu.Lock()
+	s.failures++
+	s.total++
+	s.mu.Unlock()
+}
+
+// snapshot appends this shard's currently retained latencies to dst and
+// returns the failures/total counters observed so far.
+func (s *timeoutShard) snapshot(dst []time.Duration) ([]time.Duration, uint64, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dst = append(dst, s.samples[:s.filled]...)
+	return dst, s.failures, s.total
+}
+
+// DynamicTimeout offers a timeout that adapts itself to the observed
+// distribution of operation latencies rather than tracking a single
+// average. Recent success latencies are kept in a bounded, sharded
+// reservoir; the timeout is derived from the 99th percentile of that
+// reservoir (max(minimum, P99()*k)), while failures push the timeout up
+// immediately with a multiplicative backoff so a burst of timeouts is
+// reflected without waiting for the next recomputation.
+//
+// A heavy-tailed but otherwise healthy distribution (most calls fast, a
+// rare few slow) no longer drags the timeout down to a value close to the
+// common case the way a plain average would - the tail is what the
+// percentile actually measures.
+type DynamicTimeout struct {
+	timeout int64 // atomic, nanoseconds
+	minimum int64
+
+	p50     int64  // atomic, nanoseconds, cache of the last computed P50
+	p99     int64  // atomic, nanoseconds, cache of the last computed P99
+	failPct uint64 // atomic, math.Float64bits of the last computed failure rate
+
+	calls  uint64 // atomic, total LogSuccess/LogFailure calls, used for shard routing and recompute cadence
+	shards [dynamicTimeoutShards]timeoutShard
+}
+
+// NewDynamicTimeout returns a new DynamicTimeout with the given starting
+// timeout and the minimum timeout it is allowed to settle down to.
+func NewDynamicTimeout(timeout, minimum time.Duration) *DynamicTimeout {
+	if timeout <= 0 || minimum <= 0 {
+		panic("timeout and minimum must be positive")
+	}
+	return &DynamicTimeout{timeout: int64(timeout), minimum: int64(minimum)}
+}
+
+// Timeout returns the current timeout value.
+func (dt *DynamicTimeout) Timeout() time.Duration {
+	return time.Duration(atomic.LoadInt64(&dt.timeout))
+}
+
+// P50 returns the median of the latencies currently retained in the
+// reservoir, as of the last recomputation.
+func (dt *DynamicTimeout) P50() time.Duration {
+	return time.Duration(atomic.LoadInt64(&dt.p50))
+}
+
+// P99 returns the 99th percentile of the latencies currently retained in
+// the reservoir, as of the last recomputation.
+func (dt *DynamicTimeout) P99() time.Duration {
+	return time.Duration(atomic.LoadInt64(&dt.p99))
+}
+
+// FailureRate returns the fraction of logged operations that timed out, as
+// of the last recomputation.
+func (dt *DynamicTimeout) FailureRate() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&dt.failPct))
+}
+
+// LogSuccess logs the duration of a successful action that did not hit the
+// current timeout.
+func (dt *DynamicTimeout) LogSuccess(duration time.Duration) {
+	shard := dt.shardFor(atomic.AddUint64(&dt.calls, 1))
+	shard.logSuccess(duration)
+	dt.maybeRecompute()
+}
+
+// LogFailure logs an action that reached the current timeout.
+func (dt *DynamicTimeout) LogFailure() {
+	shard := dt.shardFor(atomic.AddUint64(&dt.calls, 1))
+	shard.logFailure()
+	dt.increase()
+	dt.maybeRecompute()
+}
+
+func (dt *DynamicTimeout) shardFor(n uint64) *timeoutShard {
+	return &dt.shards[n%dynamicTimeoutShards]
+}
+
+// increase applies the multiplicative backoff for a single failure,
+// capped at dynamicTimeoutMaxTimeout.
+func (dt *DynamicTimeout) increase() {
+	for {
+		old := atomic.LoadInt64(&dt.timeout)
+		next := int64(float64(old) * dynamicTimeoutFailureMultiplier)
+		if next > int64(dynamicTimeoutMaxTimeout) {
+			next = int64(dynamicTimeoutMaxTimeout)
+		}
+		if next <= old {
+			return
+		}
+		if atomic.CompareAndSwapInt64(&dt.timeout, old, next) {
+			return
+		}
+	}
+}
+
+func (dt *DynamicTimeout) maybeRecompute() {
+	if atomic.LoadUint64(&dt.calls)%dynamicTimeoutLogSize == 0 {
+		dt.recompute()
+	}
+}
+
+// recompute merges every shard's reservoir, refreshes the P50/P99/
+// FailureRate caches and, if the P99-derived candidate is below the
+// current timeout, gently eases the timeout down towards it. Failures are
+// handled separately by increase() so a concurrent burst of timeouts is
+// never undone by a recomputation racing behind it.
+func (dt *DynamicTimeout) recompute() {
+	samples := make([]time.Duration, 0, dynamicTimeoutShards*dynamicTimeoutShardSize)
+	var failures, total uint64
+	for i := range dt.shards {
+		var f, t uint64
+		samples, f, t = dt.shards[i].snapshot(samples)
+		failures += f
+		total += t
+	}
+
+	if total > 0 {
+		atomic.StoreUint64(&dt.failPct, math.Float64bits(float64(failures)/float64(total)))
+	}
+
+	if len(samples) == 0 {
+		return
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+	p50 := samples[percentileIndex(len(samples), 0.50)]
+	p99 := samples[percentileIndex(len(samples), 0.99)]
+	atomic.StoreInt64(&dt.p50, int64(p50))
+	atomic.StoreInt64(&dt.p99, int64(p99))
+
+	candidate := int64(float64(p99) * dynamicTimeoutPercentileMultiplier)
+	if candidate < dt.minimum {
+		candidate = dt.minimum
+	}
+
+	for {
+		old := atomic.LoadInt64(&dt.timeout)
+		if candidate >= old {
+			// Failures already push the timeout up; recompute only ever
+			// eases it down, and only gently.
+			return
+		}
+		next := old - (old-candidate)/dynamicTimeoutDecreaseDamping
+		if next < dt.minimum {
+			next = dt.minimum
+		}
+		if next == old {
+			// Integer division floored the step to zero; snap straight to
+			// the candidate instead of getting stuck just above it forever.
+			next = candidate
+		}
+		if atomic.CompareAndSwapInt64(&dt.timeout, old, next) {
+			return
+		}
+	}
+}
+
+// percentileIndex returns the index into a sorted slice of length n that
+// corresponds to percentile p (0 < p <= 1).
+func percentileIndex(n int, p float64) int {
+	idx := int(float64(n-1)*p + 0.5)
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= n {
+		idx = n - 1
+	}
+	return idx
+}
@@ -1,5 +1,5 @@
 /*
- * MinIO Cloud Storage, (C) 2017 MinIO, Inc.
+ * MinIO Cloud Storage, (C) 2017-2021 MinIO, Inc.
  *
  * Licensed under the Apache License, Version 2.0 (the "License");
  * you may not use this file except in compliance with the License.
@@ -119,7 +119,10 @@ func TestDynamicTimeoutManyDecreases(t *testing.T) {
 	}
 
 	adjusted := timeout.Timeout()
-	// Check whether eventual timeout is between initial value and success timeout
+	// Check whether the eventual timeout settles strictly between the
+	// initial value and the observed success latency - the p99-derived
+	// candidate (successTimeout * k) sits above successTimeout, so the
+	// decrease should never overshoot it.
 	if initial <= adjusted || adjusted <= successTimeout {
 		t.Errorf("Failure to decrease timeout appropriately")
 	}
@@ -140,7 +143,10 @@ func TestDynamicTimeoutConcurrent(t *testing.T) {
 					timeout.LogSuccess(time.Duration(float64(time.Second) * rng.Float64()))
 				}
 				to := timeout.Timeout()
-				if to < time.Millisecond || to > time.Second {
+				// The failure-driven multiplicative increase is no
+				// longer bounded by the starting timeout, only by the
+				// configured minimum and the hard safety ceiling.
+				if to < time.Millisecond || to > dynamicTimeoutMaxTimeout {
 					panic(to)
 				}
 			}
@@ -156,7 +162,7 @@ func TestDynamicTimeoutHitMinimum(t *testing.T) {
 
 	initial := timeout.Timeout()
 
-	const successTimeout = 20 * time.Second
+	const successTimeout = 10 * time.Second
 	for l := 0; l < 100; l++ {
 		for i := 0; i < dynamicTimeoutLogSize; i++ {
 			timeout.LogSuccess(successTimeout)
@@ -196,8 +202,6 @@ func TestDynamicTimeoutAdjustExponential(t *testing.T) {
 
 	rand.Seed(0)
 
-	initial := timeout.Timeout()
-
 	for try := 0; try < 10; try++ {
 
 		testDynamicTimeoutAdjust(t, timeout, rand.ExpFloat64)
@@ -205,8 +209,11 @@ func TestDynamicTimeoutAdjustExponential(t *testing.T) {
 	}
 
 	adjusted := timeout.Timeout()
-	if initial <= adjusted {
-		t.Errorf("Failure to decrease timeout, expected %v to be less than %v", adjusted, initial)
+	if adjusted < time.Second || adjusted > dynamicTimeoutMaxTimeout {
+		t.Errorf("Timeout %v strayed outside of [minimum, ceiling]", adjusted)
+	}
+	if timeout.P99() < timeout.P50() {
+		t.Errorf("P99 %v should never be below P50 %v", timeout.P99(), timeout.P50())
 	}
 }
 
@@ -216,8 +223,6 @@ func TestDynamicTimeoutAdjustNormalized(t *testing.T) {
 
 	rand.Seed(0)
 
-	initial := timeout.Timeout()
-
 	for try := 0; try < 10; try++ {
 
 		testDynamicTimeoutAdjust(t, timeout, func() float64 {
@@ -227,7 +232,61 @@ func TestDynamicTimeoutAdjustNormalized(t *testing.T) {
 	}
 
 	adjusted := timeout.Timeout()
-	if initial <= adjusted {
-		t.Errorf("Failure to decrease timeout, expected %v to be less than %v", adjusted, initial)
+	if adjusted < time.Second || adjusted > dynamicTimeoutMaxTimeout {
+		t.Errorf("Timeout %v strayed outside of [minimum, ceiling]", adjusted)
+	}
+	if timeout.P99() < timeout.P50() {
+		t.Errorf("P99 %v should never be below P50 %v", timeout.P99(), timeout.P50())
+	}
+}
+
+// TestDynamicTimeoutHeavyTailed demonstrates the motivation behind the
+// percentile-based policy: a distribution where the overwhelming majority
+// of calls are fast and a small tail is slow. A plain average gets dragged
+// towards the common case and settles on a timeout close to the fast
+// latency, which then spuriously times out the legitimate slow tail. The
+// P99-driven timeout instead tracks the tail and keeps a comfortable
+// margin above it.
+func TestDynamicTimeoutHeavyTailed(t *testing.T) {
+
+	const fast = 10 * time.Millisecond
+	const slow = 2 * time.Second
+
+	timeout := NewDynamicTimeout(time.Minute, time.Millisecond)
+
+	for l := 0; l < 100; l++ {
+		for i := 0; i < dynamicTimeoutLogSize; i++ {
+			// 1 in 16 calls hits the slow tail, the rest are fast.
+			if i == 0 {
+				timeout.LogSuccess(slow)
+			} else {
+				timeout.LogSuccess(fast)
+			}
+		}
+	}
+
+	adjusted := timeout.Timeout()
+	if adjusted <= slow {
+		t.Errorf("Timeout %v should stay comfortably above the observed tail latency %v", adjusted, slow)
+	}
+	if timeout.P99() < slow {
+		t.Errorf("P99 %v should capture the tail latency %v, not the common case", timeout.P99(), slow)
+	}
+}
+
+func TestDynamicTimeoutFailureRate(t *testing.T) {
+
+	timeout := NewDynamicTimeout(time.Minute, time.Second)
+
+	for i := 0; i < dynamicTimeoutLogSize; i++ {
+		if i%4 == 0 {
+			timeout.LogFailure()
+		} else {
+			timeout.LogSuccess(time.Second)
+		}
+	}
+
+	if fr := timeout.FailureRate(); fr <= 0 || fr >= 1 {
+		t.Errorf("Expected a failure rate strictly between 0 and 1, got %v", fr)
 	}
 }
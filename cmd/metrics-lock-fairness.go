@@ -0,0 +1,73 @@
+/*
+ * MinIO Cloud Storage, (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// lockFairnessCollector is a prometheus.Collector that reports
+// GetLockFairnessMetrics for every LockPriority class. It is registered
+// once from init() alongside the rest of the server's collectors so the
+// per-class wait time percentiles, queue depth and starvation counts
+// requested alongside lock fairness are actually scraped, not just
+// available via GetLockFairnessMetrics for the admin API.
+type lockFairnessCollector struct {
+	queueDepth      *prometheus.Desc
+	starvationCount *prometheus.Desc
+	waitSeconds     *prometheus.Desc
+}
+
+func newLockFairnessCollector() *lockFairnessCollector {
+	labels := []string{"priority"}
+	return &lockFairnessCollector{
+		queueDepth: prometheus.NewDesc(
+			"minio_locks_fairness_queue_depth",
+			"Number of callers currently queued for a namespace lock, per priority class",
+			labels, nil,
+		),
+		starvationCount: prometheus.NewDesc(
+			"minio_locks_fairness_starvation_events_total",
+			"Number of lock acquisitions that waited past lockStarvationThreshold, per priority class",
+			labels, nil,
+		),
+		waitSeconds: prometheus.NewDesc(
+			"minio_locks_fairness_wait_seconds",
+			"Namespace lock queue wait time percentile in seconds, per priority class",
+			[]string{"priority", "quantile"}, nil,
+		),
+	}
+}
+
+func (c *lockFairnessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.queueDepth
+	ch <- c.starvationCount
+	ch <- c.waitSeconds
+}
+
+func (c *lockFairnessCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range GetLockFairnessMetrics() {
+		ch <- prometheus.MustNewConstMetric(c.queueDepth, prometheus.GaugeValue, float64(m.QueueDepth), m.Priority)
+		ch <- prometheus.MustNewConstMetric(c.starvationCount, prometheus.CounterValue, float64(m.StarvationCount), m.Priority)
+		ch <- prometheus.MustNewConstMetric(c.waitSeconds, prometheus.GaugeValue, m.WaitP50.Seconds(), m.Priority, "0.5")
+		ch <- prometheus.MustNewConstMetric(c.waitSeconds, prometheus.GaugeValue, m.WaitP99.Seconds(), m.Priority, "0.99")
+	}
+}
+
+func init() {
+	prometheus.MustRegister(newLockFairnessCollector())
+}
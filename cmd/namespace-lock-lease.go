@@ -0,0 +1,258 @@
+/*
+ * MinIO Cloud Storage, (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultLeaseTTL is used by RWLocker.Lease when called with a
+// non-positive ttl.
+const defaultLeaseTTL = 30 * time.Second
+
+// leaseRenewDivisor is the fraction of the lease TTL at which the
+// background goroutine attempts to renew it - TTL/3 gives two more
+// chances to retry a failed renewal before the lease actually lapses.
+const leaseRenewDivisor = 3
+
+// leaseRenewer is implemented by RWLocker backends that can tie lease
+// renewal directly to their own authoritative lock state, so that losing
+// the lease and losing the underlying lock are the same event reported
+// from the same place - instead of renewal only ever updating
+// globalLockGraph's best-effort diagnostic mirror of it.
+//
+// localLockInstance implements this against NsLockMap, which owns the
+// real lsync.LRWMutex for each resource. distLockInstance does not: its
+// locks live behind dsync's own peers, which do not yet expose a renewal
+// RPC, so its lease renewal falls back to globalLockGraph alone - an
+// acknowledged gap, tracked until dsync grows first-class lease support.
+type leaseRenewer interface {
+	registerLease(resources []string, opsID string, readLock bool, expiresAt time.Time)
+	renewLease(resources []string, opsID string, expiresAt time.Time) bool
+	dropLease(resources []string, opsID string) bool
+}
+
+// LockHandle is a leased lock returned by RWLocker.Lease. Unlike a bare
+// GetLock/GetRLock context, a LockHandle keeps itself alive: a background
+// goroutine renews the lease every ttl/leaseRenewDivisor, and Context() is
+// canceled the moment renewal fails for longer than ttl - for example
+// because the holder crashed and stopped heartbeating, or lost
+// connectivity to the peers that matter for quorum. This gives callers a
+// first-class signal that they've lost the lock instead of depending on
+// an external janitor to eventually notice and clean up.
+//
+// KNOWN LIMITATION: that janitor-free guarantee only holds for the local
+// (non-distributed) lock path - see RWLocker.Lease and leaseRenewer below.
+// distLockInstance does not implement leaseRenewer, so in distributed mode
+// a lapsed lease still does not release the real dsync.DRWMutex; only
+// Context() gets canceled. Distributed-mode callers still depend on
+// something else (currently nothing in this tree) to reclaim the real
+// lock from a crashed holder.
+type LockHandle struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	locker    RWLocker
+	readLock  bool
+	opsID     string
+	resources []string
+
+	mu     sync.Mutex
+	ttl    time.Duration
+	done   chan struct{}
+	extend chan time.Duration
+}
+
+func newLockHandle(ctx context.Context, locker RWLocker, opsID string, resources []string, readLock bool, ttl time.Duration) *LockHandle {
+	if ttl <= 0 {
+		ttl = defaultLeaseTTL
+	}
+	leaseCtx, cancel := context.WithCancel(ctx)
+	h := &LockHandle{
+		ctx:       leaseCtx,
+		cancel:    cancel,
+		locker:    locker,
+		readLock:  readLock,
+		opsID:     opsID,
+		resources: resources,
+		ttl:       ttl,
+		done:      make(chan struct{}),
+		extend:    make(chan time.Duration),
+	}
+
+	expiresAt := UTCNow().Add(ttl)
+	for _, resource := range resources {
+		globalLockGraph.renewHolder(resource, opsID, expiresAt)
+	}
+	if lr, ok := locker.(leaseRenewer); ok {
+		lr.registerLease(resources, opsID, readLock, expiresAt)
+	}
+
+	go h.heartbeat()
+	return h
+}
+
+// Context returns the context tied to this lease. It is canceled the
+// moment the lease can no longer be renewed, so a caller running a long
+// operation under the lock should select on it rather than assume the
+// lock stays held for as long as the operation takes.
+func (h *LockHandle) Context() context.Context {
+	return h.ctx
+}
+
+// Extend requests that the lease be kept alive for at least dur from now
+// on, instead of running out the remaining time on the previous ttl. Safe
+// to call concurrently with Unlock and with the background renewal.
+//
+// dur/leaseRenewDivisor must be a positive duration - like
+// NewDynamicTimeout, Extend panics synchronously on a bad argument rather
+// than letting it reach time.Ticker.Reset in the background heartbeat
+// goroutine, where it would panic unrecovered and crash the process
+// instead of the caller. Checking dur alone is not enough: integer
+// duration division means any dur under leaseRenewDivisor nanoseconds -
+// not just dur <= 0 - floors to zero and hits the same panic.
+func (h *LockHandle) Extend(dur time.Duration) {
+	if dur/leaseRenewDivisor <= 0 {
+		panic("dur must be long enough that dur/leaseRenewDivisor is a positive duration")
+	}
+	select {
+	case h.extend <- dur:
+	case <-h.done:
+	case <-h.ctx.Done():
+	}
+}
+
+// Unlock stops the background renewal and releases the underlying lock.
+// If a leaseRenewer backs this handle (the local, non-distributed path)
+// and its lease has already been reclaimed by reapExpiredLeasesForever,
+// dropLease reports as much and the real lock has already been released
+// for us, so there is nothing left to do. Otherwise Unlock always
+// releases the real lock itself - renewal falling behind must never be
+// treated as a reason to skip that, or the underlying mutex/dsync lock
+// leaks forever while the admin-facing lock graph claims it is free. Safe
+// to call more than once; only the first call has any effect.
+func (h *LockHandle) Unlock() {
+	h.mu.Lock()
+	select {
+	case <-h.done:
+		h.mu.Unlock()
+		return
+	default:
+		close(h.done)
+	}
+	h.mu.Unlock()
+
+	h.cancel()
+
+	for _, resource := range h.resources {
+		globalLockGraph.removeHolder(resource, h.opsID)
+	}
+
+	if lr, ok := h.locker.(leaseRenewer); ok {
+		if !lr.dropLease(h.resources, h.opsID) {
+			// reapExpiredLeasesForever already claimed this lease and
+			// released the real lock out from under us.
+			return
+		}
+	}
+
+	if h.readLock {
+		h.locker.RUnlock()
+	} else {
+		h.locker.Unlock()
+	}
+}
+
+// renewOnce attempts a single lease renewal, returning false if opsID is
+// no longer recognized as the current holder of every one of its
+// resources - most likely because a previous lease already lapsed and was
+// reaped. Backends implementing leaseRenewer are authoritative on this;
+// otherwise renewal falls back to globalLockGraph's diagnostic view.
+func (h *LockHandle) renewOnce() bool {
+	h.mu.Lock()
+	ttl := h.ttl
+	h.mu.Unlock()
+	expiresAt := UTCNow().Add(ttl)
+
+	if lr, ok := h.locker.(leaseRenewer); ok {
+		if !lr.renewLease(h.resources, h.opsID, expiresAt) {
+			return false
+		}
+	} else if !globalLockGraph.isHolder(h.resources, h.opsID) {
+		return false
+	}
+
+	for _, resource := range h.resources {
+		globalLockGraph.renewHolder(resource, h.opsID, expiresAt)
+	}
+	return true
+}
+
+func (h *LockHandle) heartbeat() {
+	h.mu.Lock()
+	ttl := h.ttl
+	h.mu.Unlock()
+
+	renewTicker := time.NewTicker(ttl / leaseRenewDivisor)
+	defer renewTicker.Stop()
+	deadline := time.NewTimer(ttl)
+	defer deadline.Stop()
+
+	for {
+		select {
+		case <-h.done:
+			return
+		case <-h.ctx.Done():
+			return
+		case d := <-h.extend:
+			h.mu.Lock()
+			h.ttl = d
+			h.mu.Unlock()
+			// Push the new expiry out right away: the next tick is d/3
+			// away, which can be later than the previous lease's expiry.
+			h.renewOnce()
+			drainTimer(deadline)
+			deadline.Reset(d)
+			renewTicker.Reset(d / leaseRenewDivisor)
+		case <-renewTicker.C:
+			if h.renewOnce() {
+				h.mu.Lock()
+				ttl := h.ttl
+				h.mu.Unlock()
+				drainTimer(deadline)
+				deadline.Reset(ttl)
+			}
+		case <-deadline.C:
+			// Renewal has failed for longer than the lease TTL: the
+			// lock is gone as far as this handle is concerned.
+			h.cancel()
+			return
+		}
+	}
+}
+
+// drainTimer resets t for reuse, draining a pending fire if Stop raced
+// with it.
+func drainTimer(t *time.Timer) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+}
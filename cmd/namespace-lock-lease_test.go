@@ -0,0 +1,203 @@
+/*
+ * MinIO Cloud Storage, (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLockHandleRenewsAndUnlocks(t *testing.T) {
+	n := NewNSLock(false)
+	li := n.NewNSLock(nil, "bucket", "object")
+
+	const ttl = 90 * time.Millisecond
+	handle, err := li.Lease(context.Background(), NewDynamicTimeout(time.Second, time.Millisecond), ttl, false)
+	if err != nil {
+		t.Fatalf("Lease failed: %v", err)
+	}
+
+	// The heartbeat should keep renewing well past the original ttl.
+	time.Sleep(4 * ttl)
+	select {
+	case <-handle.Context().Done():
+		t.Fatalf("lease context was canceled even though renewal should have kept it alive")
+	default:
+	}
+
+	handle.Unlock()
+
+	select {
+	case <-handle.Context().Done():
+	default:
+		t.Fatalf("lease context was not canceled after Unlock")
+	}
+
+	// Unlock must be safe to call more than once.
+	handle.Unlock()
+
+	// The underlying lock must actually have been released - a fresh
+	// acquire should succeed immediately.
+	li2 := n.NewNSLock(nil, "bucket", "object")
+	ctx, err := li2.GetLock(context.Background(), NewDynamicTimeout(time.Second, time.Millisecond))
+	if err != nil {
+		t.Fatalf("expected to reacquire the lock after Unlock, got: %v", err)
+	}
+	li2.Unlock()
+	_ = ctx
+}
+
+func TestLockHandleExpiresWithoutRenewal(t *testing.T) {
+	n := NewNSLock(false)
+	li := n.NewNSLock(nil, "bucket", "object")
+
+	const ttl = 60 * time.Millisecond
+	handle, err := li.Lease(context.Background(), NewDynamicTimeout(time.Second, time.Millisecond), ttl, false)
+	if err != nil {
+		t.Fatalf("Lease failed: %v", err)
+	}
+
+	// Force the lease to stop being recognized as held, simulating
+	// reapExpiredLeasesForever pruning a crashed holder, without going
+	// through Unlock (which the crashed holder would never get to call).
+	li.(*localLockInstance).ns.dropLease(handle.resources, handle.opsID)
+	for _, resource := range handle.resources {
+		globalLockGraph.removeHolder(resource, handle.opsID)
+	}
+
+	select {
+	case <-handle.Context().Done():
+	case <-time.After(2 * ttl):
+		t.Fatalf("lease context was not canceled after losing the underlying lock")
+	}
+}
+
+// TestReapExpiredLeasesOnceReleasesRealLock checks that reapExpiredLeasesOnce
+// does not just drop its own bookkeeping for a lapsed lease - it releases
+// the real lsync.LRWMutex behind it, so a concurrent holder whose process
+// crashed before calling Unlock no longer wedges the resource forever.
+func TestReapExpiredLeasesOnceReleasesRealLock(t *testing.T) {
+	n := NewNSLock(false)
+	li := n.NewNSLock(nil, "bucket", "object")
+
+	const ttl = 60 * time.Millisecond
+	handle, err := li.Lease(context.Background(), NewDynamicTimeout(time.Second, time.Millisecond), ttl, false)
+	if err != nil {
+		t.Fatalf("Lease failed: %v", err)
+	}
+
+	// Simulate the handle's own heartbeat goroutine having died with its
+	// process: the lease is still registered, but nothing is renewing it.
+	reaped := n.reapExpiredLeasesOnce(UTCNow().Add(time.Hour))
+	if len(reaped) != 1 {
+		t.Fatalf("expected exactly one lease to be reaped, got %v", reaped)
+	}
+
+	// The heartbeat's own deadline timer - unaffected by the reap above -
+	// still fires at the original ttl, at which point it notices renewal
+	// has stopped succeeding and cancels the context.
+	select {
+	case <-handle.Context().Done():
+	case <-time.After(2 * ttl):
+		t.Fatalf("lease context was not canceled after its lease was reaped")
+	}
+
+	// The real lock must actually be free now - a fresh acquire should
+	// succeed immediately rather than blocking on a lock nobody holds
+	// anymore as far as NsLockMap is concerned.
+	li2 := n.NewNSLock(nil, "bucket", "object")
+	ctx, err := li2.GetLock(context.Background(), NewDynamicTimeout(time.Second, time.Millisecond))
+	if err != nil {
+		t.Fatalf("expected to reacquire the lock after reaping, got: %v", err)
+	}
+	li2.Unlock()
+	_ = ctx
+
+	// Unlock on the reaped handle must be a safe no-op, not a double-release.
+	handle.Unlock()
+}
+
+// TestLockHandleExtend checks that Extend actually keeps the lease alive
+// past its original ttl. Rejection of bad durations is covered by the
+// TestLockHandleExtendRejects* tests below.
+func TestLockHandleExtend(t *testing.T) {
+	n := NewNSLock(false)
+	li := n.NewNSLock(nil, "bucket", "object")
+
+	const ttl = 60 * time.Millisecond
+	handle, err := li.Lease(context.Background(), NewDynamicTimeout(time.Second, time.Millisecond), ttl, false)
+	if err != nil {
+		t.Fatalf("Lease failed: %v", err)
+	}
+	defer handle.Unlock()
+
+	handle.Extend(5 * ttl)
+
+	// The lease should still be alive well past the original ttl, now
+	// that it has been extended.
+	time.Sleep(2 * ttl)
+	select {
+	case <-handle.Context().Done():
+		t.Fatalf("lease context was canceled even though Extend should have kept it alive")
+	default:
+	}
+}
+
+// TestLockHandleExtendRejectsNonPositive checks that Extend panics
+// synchronously on a non-positive duration rather than letting it reach
+// the background heartbeat goroutine's ticker.
+func TestLockHandleExtendRejectsNonPositive(t *testing.T) {
+	n := NewNSLock(false)
+	li := n.NewNSLock(nil, "bucket", "object")
+
+	handle, err := li.Lease(context.Background(), NewDynamicTimeout(time.Second, time.Millisecond), time.Minute, false)
+	if err != nil {
+		t.Fatalf("Lease failed: %v", err)
+	}
+	defer handle.Unlock()
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected Extend(0) to panic")
+		}
+	}()
+	handle.Extend(0)
+}
+
+// TestLockHandleExtendRejectsDurationThatFloorsToZero checks that Extend
+// panics even on a positive duration, if it is too small for
+// leaseRenewDivisor to divide into anything but zero - the same panic
+// Extend(0) guards against, reached through integer division instead of a
+// literal non-positive argument.
+func TestLockHandleExtendRejectsDurationThatFloorsToZero(t *testing.T) {
+	n := NewNSLock(false)
+	li := n.NewNSLock(nil, "bucket", "object")
+
+	handle, err := li.Lease(context.Background(), NewDynamicTimeout(time.Second, time.Millisecond), time.Minute, false)
+	if err != nil {
+		t.Fatalf("Lease failed: %v", err)
+	}
+	defer handle.Unlock()
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected Extend(1) to panic since 1/leaseRenewDivisor floors to 0")
+		}
+	}()
+	handle.Extend(time.Duration(1))
+}
@@ -25,6 +25,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/minio/minio/cmd/logger"
@@ -36,11 +37,75 @@ import (
 var globalLockServer *localLocker
 
 // RWLocker - locker interface to introduce GetRLock, RUnlock.
+//
+// GetLock and GetRLock take an optional LockOptions - callers that don't
+// care about scheduling priority can omit it and get the default,
+// PriorityInteractive, class.
 type RWLocker interface {
-	GetLock(ctx context.Context, timeout *DynamicTimeout) (newCtx context.Context, timedOutErr error)
+	GetLock(ctx context.Context, timeout *DynamicTimeout, opts ...LockOptions) (newCtx context.Context, timedOutErr error)
 	Unlock()
-	GetRLock(ctx context.Context, timeout *DynamicTimeout) (newCtx context.Context, timedOutErr error)
+	GetRLock(ctx context.Context, timeout *DynamicTimeout, opts ...LockOptions) (newCtx context.Context, timedOutErr error)
 	RUnlock()
+
+	// Lease behaves like GetLock (or GetRLock, if readLock is true) but
+	// returns a *LockHandle instead of a bare context: the lease is
+	// renewed automatically every ttl/3 for as long as the caller holds
+	// the handle, and LockHandle.Context() is canceled the moment
+	// renewal fails for longer than ttl. See LockHandle.
+	//
+	// KNOWN LIMITATION: only the local (non-distributed) lock path
+	// actually reclaims the underlying lock when a lease lapses - see
+	// localLockInstance vs distLockInstance below and the leaseRenewer
+	// doc comment in namespace-lock-lease.go. In distributed/erasure-coded
+	// mode, a lapsed lease still cancels the caller's Context() but does
+	// NOT release the real dsync.DRWMutex: a crashed holder wedges the
+	// resource exactly as before Lease existed. Callers in distributed
+	// mode must not treat Context() cancellation as proof the resource is
+	// free to retry against.
+	Lease(ctx context.Context, timeout *DynamicTimeout, ttl time.Duration, readLock bool, opts ...LockOptions) (*LockHandle, error)
+}
+
+// LockPriority classifies a lock request for scheduling purposes. Waiters
+// on the same resource are served in (priority, arrival order) - lower
+// numeric value means higher priority.
+type LockPriority int
+
+const (
+	// PriorityInteractive is for latency-critical, user-facing control
+	// plane operations. This is the default when no LockOptions is given.
+	PriorityInteractive LockPriority = iota
+	// PriorityBackground is for bulk data operations that can tolerate
+	// extra queuing latency.
+	PriorityBackground
+	// PriorityHealing is for background healing/scanning operations; it
+	// yields to both of the above under contention.
+	PriorityHealing
+)
+
+func (p LockPriority) String() string {
+	switch p {
+	case PriorityInteractive:
+		return "interactive"
+	case PriorityBackground:
+		return "background"
+	case PriorityHealing:
+		return "healing"
+	default:
+		return "unknown"
+	}
+}
+
+// LockOptions configures how a lock request is scheduled relative to other
+// waiters on the same resource. The zero value requests PriorityInteractive.
+type LockOptions struct {
+	Priority LockPriority
+}
+
+func lockOptionsFromVariadic(opts []LockOptions) LockOptions {
+	if len(opts) > 0 {
+		return opts[0]
+	}
+	return LockOptions{}
 }
 
 // NewNSLock - return a new name space lock map.
@@ -52,6 +117,8 @@ func NewNSLock(isDistErasure bool) *NsLockMap {
 		return &nsMutex
 	}
 	nsMutex.lockMap = make(map[string]*nsLock)
+	nsMutex.leases = make(map[string]map[string]nsLockLease)
+	go nsMutex.reapExpiredLeasesForever()
 	return &nsMutex
 }
 
@@ -61,17 +128,30 @@ type nsLock struct {
 	*lsync.LRWMutex
 }
 
+// nsLockLease is the authoritative, server-side record of a leased
+// holder's expiry for a single resource, keyed by opsID in
+// NsLockMap.leases. Unlike globalLockGraph - which is a best-effort
+// diagnostic mirror shared with the distributed path - this lives
+// alongside the real lockMap entry it describes, so reapExpiredLeasesForever
+// can release the actual lsync.LRWMutex itself rather than just updating
+// a parallel view of it.
+type nsLockLease struct {
+	readLock  bool
+	expiresAt time.Time
+}
+
 // NsLockMap - namespace lock map, provides primitives to Lock,
 // Unlock, RLock and RUnlock.
 type NsLockMap struct {
 	// Indicates if namespace is part of a distributed setup.
 	isDistErasure bool
 	lockMap       map[string]*nsLock
+	leases        map[string]map[string]nsLockLease // resource -> opsID -> lease
 	lockMapMutex  sync.Mutex
 }
 
 // Lock the namespace resource.
-func (n *NsLockMap) lock(ctx context.Context, volume string, path string, lockSource, opsID string, readLock bool, timeout time.Duration) (locked bool) {
+func (n *NsLockMap) lock(ctx context.Context, volume string, path string, lockSource, opsID string, readLock bool, timeout time.Duration, opts LockOptions) (locked bool) {
 	resource := pathJoin(volume, path)
 
 	n.lockMapMutex.Lock()
@@ -86,34 +166,67 @@ func (n *NsLockMap) lock(ctx context.Context, volume string, path string, lockSo
 	n.lockMap[resource] = nsLk
 	n.lockMapMutex.Unlock()
 
+	// Track this opsID as blocked on resource until the lock below either
+	// succeeds or times out, so the deadlock detector can see it.
+	waitCtx, cancelWait := context.WithCancel(ctx)
+	defer cancelWait()
+	globalLockGraph.addWaiter(resource, opsID, lockSource, cancelWait)
+
+	// Wait our turn in the per-resource, priority-ordered FIFO queue
+	// before attempting the actual lock below, so readers and writers of
+	// every priority class are served fairly instead of in whatever order
+	// the runtime happens to wake them.
+	release, queued := acquireFairSlot(waitCtx, resource, opts.Priority, readLock)
+	if !queued {
+		globalLockGraph.removeWaiter(resource, opsID)
+		n.derefLocked(resource)
+		return false
+	}
+	defer release()
+
 	// Locking here will block (until timeout).
 	if readLock {
-		locked = nsLk.GetRLock(ctx, opsID, lockSource, timeout)
+		locked = nsLk.GetRLock(waitCtx, opsID, lockSource, timeout)
 	} else {
-		locked = nsLk.GetLock(ctx, opsID, lockSource, timeout)
+		locked = nsLk.GetLock(waitCtx, opsID, lockSource, timeout)
 	}
 
-	if !locked { // We failed to get the lock
-		// Decrement ref count since we failed to get the lock
-		n.lockMapMutex.Lock()
-		n.lockMap[resource].ref--
-		if n.lockMap[resource].ref < 0 {
-			logger.CriticalIf(GlobalContext, errors.New("resource reference count was lower than 0"))
-		}
-		if n.lockMap[resource].ref == 0 {
-			// Remove from the map if there are no more references.
-			delete(n.lockMap, resource)
-		}
-		n.lockMapMutex.Unlock()
+	if locked {
+		globalLockGraph.promoteToHolder(resource, opsID, lockSource)
+	} else {
+		// We failed to get the lock
+		globalLockGraph.removeWaiter(resource, opsID)
+		n.derefLocked(resource)
 	}
 
 	return
 }
 
+// derefLocked drops one reference on resource, removing its entry from the
+// lock map once nothing refers to it any longer. Called after a lock
+// attempt on resource failed to actually acquire it.
+func (n *NsLockMap) derefLocked(resource string) {
+	n.lockMapMutex.Lock()
+	defer n.lockMapMutex.Unlock()
+	n.lockMap[resource].ref--
+	if n.lockMap[resource].ref < 0 {
+		logger.CriticalIf(GlobalContext, errors.New("resource reference count was lower than 0"))
+	}
+	if n.lockMap[resource].ref == 0 {
+		// Remove from the map if there are no more references.
+		delete(n.lockMap, resource)
+	}
+}
+
 // Unlock the namespace resource.
-func (n *NsLockMap) unlock(volume string, path string, readLock bool) {
-	resource := pathJoin(volume, path)
+func (n *NsLockMap) unlock(volume string, path string, readLock bool, opsID string) {
+	n.unlockResource(pathJoin(volume, path), readLock, opsID)
+}
 
+// unlockResource is the resource-keyed core of unlock, shared with
+// reapExpiredLeasesForever, which only has the joined resource name (not
+// the original volume/path) to hand.
+func (n *NsLockMap) unlockResource(resource string, readLock bool, opsID string) {
 	n.lockMapMutex.Lock()
 	defer n.lockMapMutex.Unlock()
 	if _, found := n.lockMap[resource]; !found {
@@ -124,6 +237,7 @@ func (n *NsLockMap) unlock(volume string, path string, readLock bool) {
 	} else {
 		n.lockMap[resource].Unlock()
 	}
+	globalLockGraph.removeHolder(resource, opsID)
 	n.lockMap[resource].ref--
 	if n.lockMap[resource].ref < 0 {
 		logger.CriticalIf(GlobalContext, errors.New("resource reference count was lower than 0"))
@@ -134,54 +248,246 @@ func (n *NsLockMap) unlock(volume string, path string, readLock bool) {
 	}
 }
 
+// registerLease records the initial lease expiry for opsID's hold on every
+// resource in resources. Called once when a Lease is first acquired; see
+// renewLease for subsequent heartbeats.
+func (n *NsLockMap) registerLease(resources []string, opsID string, readLock bool, expiresAt time.Time) {
+	n.lockMapMutex.Lock()
+	defer n.lockMapMutex.Unlock()
+	for _, resource := range resources {
+		if n.leases[resource] == nil {
+			n.leases[resource] = make(map[string]nsLockLease)
+		}
+		n.leases[resource][opsID] = nsLockLease{readLock: readLock, expiresAt: expiresAt}
+	}
+}
+
+// renewLease extends opsID's lease on every resource in resources to
+// expiresAt and reports whether it is still recognized as the current
+// lease holder everywhere. False means reapExpiredLeasesForever already
+// reclaimed - and released - the real lock on at least one of them, most
+// likely because this process stopped heartbeating for longer than the
+// previous lease allowed.
+func (n *NsLockMap) renewLease(resources []string, opsID string, expiresAt time.Time) bool {
+	n.lockMapMutex.Lock()
+	defer n.lockMapMutex.Unlock()
+	for _, resource := range resources {
+		if _, ok := n.leases[resource][opsID]; !ok {
+			return false
+		}
+	}
+	for _, resource := range resources {
+		lease := n.leases[resource][opsID]
+		lease.expiresAt = expiresAt
+		n.leases[resource][opsID] = lease
+	}
+	return true
+}
+
+// dropLease removes opsID's lease bookkeeping for resources and reports
+// whether it was still present. False means reapExpiredLeasesForever
+// already claimed and released the real lock for at least one resource,
+// so the caller must not release it again.
+func (n *NsLockMap) dropLease(resources []string, opsID string) bool {
+	n.lockMapMutex.Lock()
+	defer n.lockMapMutex.Unlock()
+	claimed := true
+	for _, resource := range resources {
+		leases := n.leases[resource]
+		if _, ok := leases[opsID]; !ok {
+			claimed = false
+			continue
+		}
+		delete(leases, opsID)
+		if len(leases) == 0 {
+			delete(n.leases, resource)
+		}
+	}
+	return claimed
+}
+
+// reapExpiredLeasesForever is the server-side half of lock leasing for
+// local (non-distributed) mode: every nsLockDeadlockCheckInterval it finds
+// every lease that lapsed without being renewed and releases the real
+// lsync.LRWMutex backing it directly, so a crashed holder - whose own
+// heartbeat goroutine died with it - can no longer wedge the resource once
+// its lease runs out. This is the real counterpart to globalLockGraph's
+// reapExpiredForever, which only ever updates the admin-facing diagnostic
+// view and never touches an actual lock.
+func (n *NsLockMap) reapExpiredLeasesForever() {
+	ticker := time.NewTicker(nsLockDeadlockCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		n.reapExpiredLeasesOnce(UTCNow())
+	}
+}
+
+// nsLockExpiredLease identifies a lease that reapExpiredLeasesOnce found
+// past its expiresAt and is about to release.
+type nsLockExpiredLease struct {
+	resource string
+	opsID    string
+	readLock bool
+}
+
+// reapExpiredLeasesOnce scans every lease for one that lapsed before now
+// without being renewed, releases the real lock backing it and returns
+// what it reaped. Split out from reapExpiredLeasesForever so it can be
+// exercised directly in tests without waiting on the ticker.
+func (n *NsLockMap) reapExpiredLeasesOnce(now time.Time) []nsLockExpiredLease {
+	var expired []nsLockExpiredLease
+
+	n.lockMapMutex.Lock()
+	for resource, leases := range n.leases {
+		for opsID, lease := range leases {
+			if now.After(lease.expiresAt) {
+				expired = append(expired, nsLockExpiredLease{resource, opsID, lease.readLock})
+				delete(leases, opsID)
+			}
+		}
+		if len(leases) == 0 {
+			delete(n.leases, resource)
+		}
+	}
+	n.lockMapMutex.Unlock()
+
+	for _, e := range expired {
+		logger.LogIf(GlobalContext, fmt.Errorf("reaping expired lock lease held by %s on %s", e.opsID, e.resource))
+		n.unlockResource(e.resource, e.readLock, e.opsID)
+	}
+	return expired
+}
+
 // dsync's distributed lock instance.
+//
+// distLockInstance deliberately does not implement leaseRenewer: dsync's
+// peers expose no renewal RPC, so a Lease taken out through this type
+// falls back to globalLockGraph's diagnostic mirror alone for renewal. See
+// the KNOWN LIMITATION on RWLocker.Lease and LockHandle - a lapsed lease
+// here cancels the caller's Context() but never releases the real
+// dsync.DRWMutex.
 type distLockInstance struct {
 	rwMutex *dsync.DRWMutex
 	opsID   string
+	names   []string
+}
+
+// fairnessKey identifies this lock request as a single unit for scheduling
+// purposes - dsync.DRWMutex acquires every name atomically as one lock, so
+// fairness is arbitrated on the combined key rather than per name.
+func (di *distLockInstance) fairnessKey() string {
+	return strings.Join(di.names, ",")
 }
 
 // Lock - block until write lock is taken or timeout has occurred.
-func (di *distLockInstance) GetLock(ctx context.Context, timeout *DynamicTimeout) (context.Context, error) {
+func (di *distLockInstance) GetLock(ctx context.Context, timeout *DynamicTimeout, opts ...LockOptions) (context.Context, error) {
 	lockSource := getSource(2)
+	priority := lockOptionsFromVariadic(opts).Priority
 	start := UTCNow()
 
 	newCtx, cancel := context.WithCancel(ctx)
+	for _, name := range di.names {
+		globalLockGraph.addWaiter(name, di.opsID, lockSource, cancel)
+	}
+
+	const readLock = false
+	release, queued := acquireFairSlot(newCtx, di.fairnessKey(), priority, readLock)
+	if !queued {
+		cancel()
+		for _, name := range di.names {
+			globalLockGraph.removeWaiter(name, di.opsID)
+		}
+		return ctx, OperationTimedOut{}
+	}
+	defer release()
+
 	if !di.rwMutex.GetLock(newCtx, cancel, di.opsID, lockSource, dsync.Options{
 		Timeout: timeout.Timeout(),
 	}) {
 		timeout.LogFailure()
+		for _, name := range di.names {
+			globalLockGraph.removeWaiter(name, di.opsID)
+		}
 		return ctx, OperationTimedOut{}
 	}
+	for _, name := range di.names {
+		globalLockGraph.promoteToHolder(name, di.opsID, lockSource)
+	}
 	timeout.LogSuccess(UTCNow().Sub(start))
 	return newCtx, nil
 }
 
 // Unlock - block until write lock is released.
 func (di *distLockInstance) Unlock() {
+	for _, name := range di.names {
+		globalLockGraph.removeHolder(name, di.opsID)
+	}
 	di.rwMutex.Unlock()
 }
 
 // RLock - block until read lock is taken or timeout has occurred.
-func (di *distLockInstance) GetRLock(ctx context.Context, timeout *DynamicTimeout) (context.Context, error) {
+func (di *distLockInstance) GetRLock(ctx context.Context, timeout *DynamicTimeout, opts ...LockOptions) (context.Context, error) {
 	lockSource := getSource(2)
+	priority := lockOptionsFromVariadic(opts).Priority
 	start := UTCNow()
 
 	newCtx, cancel := context.WithCancel(ctx)
-	if !di.rwMutex.GetRLock(ctx, cancel, di.opsID, lockSource, dsync.Options{
+	for _, name := range di.names {
+		globalLockGraph.addWaiter(name, di.opsID, lockSource, cancel)
+	}
+
+	const readLock = true
+	release, queued := acquireFairSlot(newCtx, di.fairnessKey(), priority, readLock)
+	if !queued {
+		cancel()
+		for _, name := range di.names {
+			globalLockGraph.removeWaiter(name, di.opsID)
+		}
+		return ctx, OperationTimedOut{}
+	}
+	defer release()
+
+	if !di.rwMutex.GetRLock(newCtx, cancel, di.opsID, lockSource, dsync.Options{
 		Timeout: timeout.Timeout(),
 	}) {
 		timeout.LogFailure()
+		for _, name := range di.names {
+			globalLockGraph.removeWaiter(name, di.opsID)
+		}
 		return ctx, OperationTimedOut{}
 	}
+	for _, name := range di.names {
+		globalLockGraph.promoteToHolder(name, di.opsID, lockSource)
+	}
 	timeout.LogSuccess(UTCNow().Sub(start))
 	return newCtx, nil
 }
 
 // RUnlock - block until read lock is released.
 func (di *distLockInstance) RUnlock() {
+	for _, name := range di.names {
+		globalLockGraph.removeHolder(name, di.opsID)
+	}
 	di.rwMutex.RUnlock()
 }
 
+// Lease - see RWLocker.Lease.
+func (di *distLockInstance) Lease(ctx context.Context, timeout *DynamicTimeout, ttl time.Duration, readLock bool, opts ...LockOptions) (*LockHandle, error) {
+	var (
+		newCtx context.Context
+		err    error
+	)
+	if readLock {
+		newCtx, err = di.GetRLock(ctx, timeout, opts...)
+	} else {
+		newCtx, err = di.GetLock(ctx, timeout, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newLockHandle(newCtx, di, di.opsID, di.names, readLock, ttl), nil
+}
+
 // localLockInstance - frontend/top-level interface for namespace locks.
 type localLockInstance struct {
 	ns     *NsLockMap
@@ -196,27 +502,29 @@ type localLockInstance struct {
 func (n *NsLockMap) NewNSLock(lockers func() ([]dsync.NetLocker, string), volume string, paths ...string) RWLocker {
 	opsID := mustGetUUID()
 	if n.isDistErasure {
+		names := pathsJoinPrefix(volume, paths...)
 		drwmutex := dsync.NewDRWMutex(&dsync.Dsync{
 			GetLockers: lockers,
-		}, pathsJoinPrefix(volume, paths...)...)
-		return &distLockInstance{drwmutex, opsID}
+		}, names...)
+		return &distLockInstance{drwmutex, opsID, names}
 	}
 	sort.Strings(paths)
 	return &localLockInstance{n, volume, paths, opsID}
 }
 
 // Lock - block until write lock is taken or timeout has occurred.
-func (li *localLockInstance) GetLock(ctx context.Context, timeout *DynamicTimeout) (_ context.Context, timedOutErr error) {
+func (li *localLockInstance) GetLock(ctx context.Context, timeout *DynamicTimeout, opts ...LockOptions) (_ context.Context, timedOutErr error) {
 	lockSource := getSource(2)
 	start := UTCNow()
 	const readLock = false
+	lockOpts := lockOptionsFromVariadic(opts)
 	success := make([]int, len(li.paths))
 	for i, path := range li.paths {
-		if !li.ns.lock(ctx, li.volume, path, lockSource, li.opsID, readLock, timeout.Timeout()) {
+		if !li.ns.lock(ctx, li.volume, path, lockSource, li.opsID, readLock, timeout.Timeout(), lockOpts) {
 			timeout.LogFailure()
 			for si, sint := range success {
 				if sint == 1 {
-					li.ns.unlock(li.volume, li.paths[si], readLock)
+					li.ns.unlock(li.volume, li.paths[si], readLock, li.opsID)
 				}
 			}
 			return nil, OperationTimedOut{}
@@ -231,22 +539,23 @@ func (li *localLockInstance) GetLock(ctx context.Context, timeout *DynamicTimeou
 func (li *localLockInstance) Unlock() {
 	const readLock = false
 	for _, path := range li.paths {
-		li.ns.unlock(li.volume, path, readLock)
+		li.ns.unlock(li.volume, path, readLock, li.opsID)
 	}
 }
 
 // RLock - block until read lock is taken or timeout has occurred.
-func (li *localLockInstance) GetRLock(ctx context.Context, timeout *DynamicTimeout) (_ context.Context, timedOutErr error) {
+func (li *localLockInstance) GetRLock(ctx context.Context, timeout *DynamicTimeout, opts ...LockOptions) (_ context.Context, timedOutErr error) {
 	lockSource := getSource(2)
 	start := UTCNow()
 	const readLock = true
+	lockOpts := lockOptionsFromVariadic(opts)
 	success := make([]int, len(li.paths))
 	for i, path := range li.paths {
-		if !li.ns.lock(ctx, li.volume, path, lockSource, li.opsID, readLock, timeout.Timeout()) {
+		if !li.ns.lock(ctx, li.volume, path, lockSource, li.opsID, readLock, timeout.Timeout(), lockOpts) {
 			timeout.LogFailure()
 			for si, sint := range success {
 				if sint == 1 {
-					li.ns.unlock(li.volume, li.paths[si], readLock)
+					li.ns.unlock(li.volume, li.paths[si], readLock, li.opsID)
 				}
 			}
 			return nil, OperationTimedOut{}
@@ -261,8 +570,45 @@ func (li *localLockInstance) GetRLock(ctx context.Context, timeout *DynamicTimeo
 func (li *localLockInstance) RUnlock() {
 	const readLock = true
 	for _, path := range li.paths {
-		li.ns.unlock(li.volume, path, readLock)
+		li.ns.unlock(li.volume, path, readLock, li.opsID)
+	}
+}
+
+// Lease - see RWLocker.Lease.
+func (li *localLockInstance) Lease(ctx context.Context, timeout *DynamicTimeout, ttl time.Duration, readLock bool, opts ...LockOptions) (*LockHandle, error) {
+	var (
+		newCtx context.Context
+		err    error
+	)
+	if readLock {
+		newCtx, err = li.GetRLock(ctx, timeout, opts...)
+	} else {
+		newCtx, err = li.GetLock(ctx, timeout, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]string, len(li.paths))
+	for i, path := range li.paths {
+		resources[i] = pathJoin(li.volume, path)
 	}
+	return newLockHandle(newCtx, li, li.opsID, resources, readLock, ttl), nil
+}
+
+// registerLease, renewLease and dropLease make localLockInstance satisfy
+// leaseRenewer by forwarding straight to its NsLockMap, which is the real,
+// authoritative holder of the lsync.LRWMutex these resources name - unlike
+// globalLockGraph, which only ever mirrors it for diagnostics.
+func (li *localLockInstance) registerLease(resources []string, opsID string, readLock bool, expiresAt time.Time) {
+	li.ns.registerLease(resources, opsID, readLock, expiresAt)
+}
+
+func (li *localLockInstance) renewLease(resources []string, opsID string, expiresAt time.Time) bool {
+	return li.ns.renewLease(resources, opsID, expiresAt)
+}
+
+func (li *localLockInstance) dropLease(resources []string, opsID string) bool {
+	return li.ns.dropLease(resources, opsID)
 }
 
 func getSource(n int) string {
@@ -279,3 +625,643 @@ func getSource(n int) string {
 
 	return fmt.Sprintf("[%s:%d:%s()]", filename, lineNum, funcName)
 }
+
+// nsLockDeadlockCheckInterval is how often the background goroutine scans
+// the wait-for graph for cycles.
+const nsLockDeadlockCheckInterval = 10 * time.Second
+
+// globalLockGraph is the process-wide wait-for graph covering both local
+// (NsLockMap) and distributed (dsync) locks, keyed by resource name so
+// that the two paths share a single view for deadlock detection and
+// introspection.
+var globalLockGraph = newLockGraphTracker()
+
+func init() {
+	go globalLockGraph.detectDeadlocksForever()
+	go globalLockGraph.reapExpiredForever()
+}
+
+// lockFrame is one entry in the wait-for graph: either a current holder of
+// a resource, or an opsID currently blocked waiting to acquire it.
+type lockFrame struct {
+	opsID      string
+	lockSource string
+	since      time.Time
+	cancel     context.CancelFunc // only set for waiters, used to abort on deadlock
+	expiresAt  time.Time          // only set for leased holders, see LockHandle
+}
+
+// lockGraphTracker records, per resource, the set of current holders and
+// waiters. It is intentionally independent of lsync.LRWMutex and
+// dsync.DRWMutex - both NsLockMap and distLockInstance report into it
+// around the actual (un)locking calls, so it stays in sync without either
+// of those locker implementations knowing it exists.
+type lockGraphTracker struct {
+	mu      sync.Mutex
+	holders map[string][]lockFrame
+	waiters map[string][]lockFrame
+}
+
+func newLockGraphTracker() *lockGraphTracker {
+	return &lockGraphTracker{
+		holders: make(map[string][]lockFrame),
+		waiters: make(map[string][]lockFrame),
+	}
+}
+
+func (g *lockGraphTracker) addWaiter(resource, opsID, lockSource string, cancel context.CancelFunc) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.waiters[resource] = append(g.waiters[resource], lockFrame{
+		opsID:      opsID,
+		lockSource: lockSource,
+		since:      UTCNow(),
+		cancel:     cancel,
+	})
+}
+
+func (g *lockGraphTracker) removeWaiter(resource, opsID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.waiters[resource] = removeLockFrame(g.waiters[resource], opsID)
+	if len(g.waiters[resource]) == 0 {
+		delete(g.waiters, resource)
+	}
+}
+
+func (g *lockGraphTracker) promoteToHolder(resource, opsID, lockSource string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.waiters[resource] = removeLockFrame(g.waiters[resource], opsID)
+	if len(g.waiters[resource]) == 0 {
+		delete(g.waiters, resource)
+	}
+	g.holders[resource] = append(g.holders[resource], lockFrame{
+		opsID:      opsID,
+		lockSource: lockSource,
+		since:      UTCNow(),
+	})
+}
+
+func (g *lockGraphTracker) removeHolder(resource, opsID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.holders[resource] = removeLockFrame(g.holders[resource], opsID)
+	if len(g.holders[resource]) == 0 {
+		delete(g.holders, resource)
+	}
+}
+
+// renewHolder extends opsID's lease on resource to expiresAt. It is a
+// no-op if opsID is not currently a holder of resource - most likely
+// because reapExpiredForever already dropped it once its previous lease
+// lapsed.
+func (g *lockGraphTracker) renewHolder(resource, opsID string, expiresAt time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	frames := g.holders[resource]
+	for i := range frames {
+		if frames[i].opsID == opsID {
+			frames[i].expiresAt = expiresAt
+			return
+		}
+	}
+}
+
+// isHolder reports whether opsID is still a current holder of every
+// resource in resources.
+func (g *lockGraphTracker) isHolder(resources []string, opsID string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, resource := range resources {
+		found := false
+		for _, f := range g.holders[resource] {
+			if f.opsID == opsID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// reapExpiredForever keeps the admin-facing lock graph honest: every
+// nsLockDeadlockCheckInterval it drops any holder whose lease has lapsed
+// without being renewed, mirroring what actually happened to the
+// underlying lock. For the local (non-distributed) path, the real release
+// is NsLockMap.reapExpiredLeasesForever's job - this only ever updates the
+// diagnostic view, never an actual lock, so it must not be relied on as
+// the mechanism that reclaims a crashed holder's lock.
+func (g *lockGraphTracker) reapExpiredForever() {
+	ticker := time.NewTicker(nsLockDeadlockCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		now := UTCNow()
+		g.mu.Lock()
+		for resource, frames := range g.holders {
+			kept := frames[:0]
+			for _, f := range frames {
+				if !f.expiresAt.IsZero() && now.After(f.expiresAt) {
+					continue
+				}
+				kept = append(kept, f)
+			}
+			if len(kept) == 0 {
+				delete(g.holders, resource)
+			} else {
+				g.holders[resource] = kept
+			}
+		}
+		g.mu.Unlock()
+	}
+}
+
+func removeLockFrame(frames []lockFrame, opsID string) []lockFrame {
+	out := frames[:0]
+	for _, f := range frames {
+		if f.opsID != opsID {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// waitForEdges returns a snapshot of the wait-for graph as opsID -> the
+// opsIDs of every current holder of the resource it is blocked on.
+func (g *lockGraphTracker) waitForEdges() map[string][]string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	edges := make(map[string][]string)
+	for resource, waiters := range g.waiters {
+		holders := g.holders[resource]
+		if len(holders) == 0 {
+			continue
+		}
+		for _, w := range waiters {
+			for _, h := range holders {
+				edges[w.opsID] = append(edges[w.opsID], h.opsID)
+			}
+		}
+	}
+	return edges
+}
+
+// detectCycle runs a DFS-based cycle detection (the classic white/gray/
+// black coloring) over the wait-for graph and returns the first cycle
+// found, expressed as the ordered sequence of opsIDs involved, or nil if
+// the graph is currently acyclic.
+func detectCycle(edges map[string][]string) []string {
+	const (
+		white = iota
+		gray
+		black
+	)
+	color := make(map[string]int)
+	var path []string
+	var cycle []string
+
+	var visit func(node string) bool
+	visit = func(node string) bool {
+		color[node] = gray
+		path = append(path, node)
+		for _, next := range edges[node] {
+			switch color[next] {
+			case gray:
+				for i, n := range path {
+					if n == next {
+						cycle = append([]string{}, path[i:]...)
+						cycle = append(cycle, next)
+						return true
+					}
+				}
+			case white:
+				if visit(next) {
+					return true
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		color[node] = black
+		return false
+	}
+
+	// Iterate in a stable order so repeated scans of the same graph report
+	// the same cycle.
+	nodes := make([]string, 0, len(edges))
+	for node := range edges {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	for _, node := range nodes {
+		if color[node] == white {
+			if visit(node) {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// detectDeadlocksForever is the background detector started once from
+// init(): every nsLockDeadlockCheckInterval it scans the wait-for graph
+// and, on finding a cycle, logs it with the lockSource of every waiter
+// involved and aborts the youngest waiter in the cycle to break the
+// deadlock.
+func (g *lockGraphTracker) detectDeadlocksForever() {
+	ticker := time.NewTicker(nsLockDeadlockCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		g.detectDeadlocksOnce()
+	}
+}
+
+// detectDeadlocksOnce scans the wait-for graph for a single cycle and, if
+// one exists, logs it and aborts its youngest waiter, returning the cycle
+// it broke (or nil if the graph was acyclic). Split out from
+// detectDeadlocksForever so it can be exercised directly in tests without
+// waiting on the ticker, the same way reapExpiredLeasesOnce is for lease
+// expiry.
+func (g *lockGraphTracker) detectDeadlocksOnce() []string {
+	cycle := detectCycle(g.waitForEdges())
+	if cycle == nil {
+		return nil
+	}
+	g.mu.Lock()
+	frames := make([]string, 0, len(cycle))
+	for _, opsID := range cycle {
+		frame := opsID
+		for _, waiters := range g.waiters {
+			for _, f := range waiters {
+				if f.opsID == opsID {
+					frame = opsID + f.lockSource
+				}
+			}
+		}
+		frames = append(frames, frame)
+	}
+	g.mu.Unlock()
+	logger.LogIf(GlobalContext, fmt.Errorf("lock deadlock detected: %s", strings.Join(frames, " -> ")))
+	g.abortYoungestWaiter(cycle)
+	return cycle
+}
+
+// abortYoungestWaiter cancels the context of whichever waiter in opsIDs
+// started waiting most recently, causing its blocked GetLock/GetRLock call
+// to fail with OperationTimedOut instead of wedging forever.
+func (g *lockGraphTracker) abortYoungestWaiter(opsIDs []string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	inCycle := make(map[string]bool, len(opsIDs))
+	for _, id := range opsIDs {
+		inCycle[id] = true
+	}
+
+	var youngest *lockFrame
+	for _, frames := range g.waiters {
+		for i := range frames {
+			f := &frames[i]
+			if !inCycle[f.opsID] || f.cancel == nil {
+				continue
+			}
+			if youngest == nil || f.since.After(youngest.since) {
+				youngest = f
+			}
+		}
+	}
+	if youngest != nil {
+		youngest.cancel()
+	}
+}
+
+// LockHolderInfo is the JSON view of a single lock holder or waiter,
+// returned by the lock graph introspection admin endpoints.
+type LockHolderInfo struct {
+	OpsID      string    `json:"opsId"`
+	LockSource string    `json:"lockSource"`
+	Since      time.Time `json:"since"`
+}
+
+// LockResourceState is the per-resource snapshot returned by the
+// /minio/admin/v3/locks/graph endpoint.
+type LockResourceState struct {
+	Resource string           `json:"resource"`
+	Holders  []LockHolderInfo `json:"holders"`
+	Waiters  []LockHolderInfo `json:"waiters"`
+}
+
+// Graph returns a point-in-time snapshot of every resource that currently
+// has at least one holder or waiter.
+func (g *lockGraphTracker) Graph() []LockResourceState {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	resources := make(map[string]struct{}, len(g.holders)+len(g.waiters))
+	for resource := range g.holders {
+		resources[resource] = struct{}{}
+	}
+	for resource := range g.waiters {
+		resources[resource] = struct{}{}
+	}
+
+	states := make([]LockResourceState, 0, len(resources))
+	for resource := range resources {
+		states = append(states, LockResourceState{
+			Resource: resource,
+			Holders:  toLockHolderInfo(g.holders[resource]),
+			Waiters:  toLockHolderInfo(g.waiters[resource]),
+		})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Resource < states[j].Resource })
+	return states
+}
+
+// Deadlocks returns every wait-for cycle currently present in the graph,
+// each expressed as the ordered sequence of opsIDs involved. An empty
+// slice means no deadlock is currently detected.
+func (g *lockGraphTracker) Deadlocks() [][]string {
+	edges := g.waitForEdges()
+
+	cycles := [][]string{}
+	for {
+		cycle := detectCycle(edges)
+		if cycle == nil {
+			return cycles
+		}
+		cycles = append(cycles, cycle)
+		// Drop the cycle's nodes so a further scan can find any
+		// remaining, independent cycles instead of looping forever.
+		for _, node := range cycle {
+			delete(edges, node)
+		}
+	}
+}
+
+func toLockHolderInfo(frames []lockFrame) []LockHolderInfo {
+	out := make([]LockHolderInfo, 0, len(frames))
+	for _, f := range frames {
+		out = append(out, LockHolderInfo{OpsID: f.opsID, LockSource: f.lockSource, Since: f.since})
+	}
+	return out
+}
+
+// maxFairReaderBatch bounds how many readers the queue admits to run
+// concurrently; once that many are active, the next reader in line waits
+// until one of them is released. Writers are protected by queue order
+// instead: a reader queued behind a waiting writer is never admitted
+// ahead of it.
+const maxFairReaderBatch = 32
+
+// fairWaiter is a single entry in a fairQueue: either a reader or a writer,
+// ready is closed once it has been admitted.
+type fairWaiter struct {
+	seq      uint64
+	priority LockPriority
+	readLock bool
+	ready    chan struct{}
+}
+
+// fairQueue arbitrates access to a single resource across every waiter
+// currently queued on it, in (priority, arrival order). Readers of the
+// class at the front of the line are allowed to run concurrently, batched
+// up to maxFairReaderBatch, but a writer is never skipped over. This
+// writer-preferring FIFO with reader batching is applied here, at the
+// NsLockMap/distLockInstance layer that both the lsync.LRWMutex and the
+// dsync.DRWMutex paths already funnel through.
+type fairQueue struct {
+	mu            sync.Mutex
+	nextSeq       uint64
+	waiters       []*fairWaiter
+	activeWriter  bool
+	activeReaders int
+}
+
+func (q *fairQueue) enqueue(priority LockPriority, readLock bool) *fairWaiter {
+	q.mu.Lock()
+	w := &fairWaiter{seq: q.nextSeq, priority: priority, readLock: readLock, ready: make(chan struct{})}
+	q.nextSeq++
+	q.waiters = append(q.waiters, w)
+	q.sortLocked()
+	q.admitLocked()
+	q.mu.Unlock()
+	return w
+}
+
+func (q *fairQueue) sortLocked() {
+	sort.SliceStable(q.waiters, func(i, j int) bool {
+		a, b := q.waiters[i], q.waiters[j]
+		if a.priority != b.priority {
+			return a.priority < b.priority
+		}
+		return a.seq < b.seq
+	})
+}
+
+// admitLocked grants access to as many front-of-line waiters as the
+// fairness policy currently allows. Must be called with q.mu held.
+func (q *fairQueue) admitLocked() {
+	if q.activeWriter {
+		return
+	}
+	for len(q.waiters) > 0 {
+		front := q.waiters[0]
+		if !front.readLock {
+			if q.activeReaders > 0 {
+				return
+			}
+			q.waiters = q.waiters[1:]
+			q.activeWriter = true
+			close(front.ready)
+			return
+		}
+		if q.activeReaders >= maxFairReaderBatch {
+			return
+		}
+		q.waiters = q.waiters[1:]
+		q.activeReaders++
+		close(front.ready)
+	}
+}
+
+// release hands the slot held by w back to the queue and admits whoever is
+// next in line.
+func (q *fairQueue) release(w *fairWaiter) {
+	q.mu.Lock()
+	if w.readLock {
+		q.activeReaders--
+	} else {
+		q.activeWriter = false
+	}
+	q.admitLocked()
+	q.mu.Unlock()
+}
+
+// cancel withdraws w from the queue if it is still waiting. If w was
+// concurrently admitted (lost the race against its own context
+// cancellation), it is released instead so the slot isn't leaked.
+func (q *fairQueue) cancel(w *fairWaiter) {
+	q.mu.Lock()
+	for i, waiter := range q.waiters {
+		if waiter == w {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			q.mu.Unlock()
+			return
+		}
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		q.release(w)
+	default:
+	}
+}
+
+func (q *fairQueue) idleLocked() bool {
+	return len(q.waiters) == 0 && !q.activeWriter && q.activeReaders == 0
+}
+
+var (
+	fairQueuesMutex sync.Mutex
+	fairQueues      = make(map[string]*fairQueue)
+)
+
+func getFairQueue(resource string) *fairQueue {
+	fairQueuesMutex.Lock()
+	defer fairQueuesMutex.Unlock()
+	q, ok := fairQueues[resource]
+	if !ok {
+		q = &fairQueue{}
+		fairQueues[resource] = q
+	}
+	return q
+}
+
+// releaseFairQueueIfIdle drops resource's entry from the registry once it
+// has neither waiters nor active holders, mirroring how NsLockMap prunes
+// lockMap entries that no longer have any references.
+func releaseFairQueueIfIdle(resource string, q *fairQueue) {
+	fairQueuesMutex.Lock()
+	defer fairQueuesMutex.Unlock()
+	q.mu.Lock()
+	idle := q.idleLocked()
+	q.mu.Unlock()
+	if idle && fairQueues[resource] == q {
+		delete(fairQueues, resource)
+	}
+}
+
+// lockStarvationThreshold is the queue wait duration past which a lock
+// acquisition is counted as a starvation event for its priority class.
+const lockStarvationThreshold = 5 * time.Second
+
+// lockClassStatsSize bounds the wait-time reservoir kept per priority
+// class for percentile reporting.
+const lockClassStatsSize = 256
+
+// lockClassStats tracks queue depth, starvation events and a bounded
+// reservoir of recent queue wait times for a single LockPriority class.
+type lockClassStats struct {
+	mu          sync.Mutex
+	waits       [lockClassStatsSize]time.Duration
+	next        int
+	filled      int
+	queueDepth  int64
+	starvations int64
+}
+
+func (s *lockClassStats) enqueued() {
+	atomic.AddInt64(&s.queueDepth, 1)
+}
+
+func (s *lockClassStats) dequeued(wait time.Duration) {
+	atomic.AddInt64(&s.queueDepth, -1)
+	if wait > lockStarvationThreshold {
+		atomic.AddInt64(&s.starvations, 1)
+	}
+	s.mu.Lock()
+	s.waits[s.next] = wait
+	s.next = (s.next + 1) % lockClassStatsSize
+	if s.filled < lockClassStatsSize {
+		s.filled++
+	}
+	s.mu.Unlock()
+}
+
+// LockClassMetrics is a point-in-time snapshot of queue depth, starvation
+// count and wait-time percentiles for a single LockPriority class, meant
+// to back a Prometheus collector alongside the rest of the server metrics.
+type LockClassMetrics struct {
+	Priority        string        `json:"priority"`
+	QueueDepth      int64         `json:"queueDepth"`
+	StarvationCount int64         `json:"starvationEvents"`
+	WaitP50         time.Duration `json:"waitP50"`
+	WaitP99         time.Duration `json:"waitP99"`
+}
+
+func (s *lockClassStats) snapshot(priority LockPriority) LockClassMetrics {
+	s.mu.Lock()
+	samples := append([]time.Duration(nil), s.waits[:s.filled]...)
+	s.mu.Unlock()
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+	m := LockClassMetrics{
+		Priority:        priority.String(),
+		QueueDepth:      atomic.LoadInt64(&s.queueDepth),
+		StarvationCount: atomic.LoadInt64(&s.starvations),
+	}
+	if len(samples) > 0 {
+		m.WaitP50 = samples[percentileIndex(len(samples), 0.50)]
+		m.WaitP99 = samples[percentileIndex(len(samples), 0.99)]
+	}
+	return m
+}
+
+var globalLockFairnessStats = [...]*lockClassStats{
+	PriorityInteractive: {},
+	PriorityBackground:  {},
+	PriorityHealing:     {},
+}
+
+// GetLockFairnessMetrics returns a snapshot of queue depth, starvation
+// count and wait-time percentiles for every lock priority class.
+func GetLockFairnessMetrics() []LockClassMetrics {
+	out := make([]LockClassMetrics, 0, len(globalLockFairnessStats))
+	for i, s := range globalLockFairnessStats {
+		out = append(out, s.snapshot(LockPriority(i)))
+	}
+	return out
+}
+
+// acquireFairSlot enqueues a lock request of the given priority and kind
+// on resource's fairQueue and blocks until it is admitted or ctx is done.
+// The returned release func must be called exactly once, after the actual
+// lock attempt below has run to completion (whether it succeeded or not),
+// to let the next waiter in line proceed.
+func acquireFairSlot(ctx context.Context, resource string, priority LockPriority, readLock bool) (release func(), queued bool) {
+	stats := globalLockFairnessStats[priority]
+	stats.enqueued()
+
+	q := getFairQueue(resource)
+	w := q.enqueue(priority, readLock)
+	start := UTCNow()
+
+	select {
+	case <-w.ready:
+		stats.dequeued(UTCNow().Sub(start))
+		return func() {
+			q.release(w)
+			releaseFairQueueIfIdle(resource, q)
+		}, true
+	case <-ctx.Done():
+		q.cancel(w)
+		stats.dequeued(UTCNow().Sub(start))
+		releaseFairQueueIfIdle(resource, q)
+		return nil, false
+	}
+}
@@ -0,0 +1,334 @@
+/*
+ * MinIO Cloud Storage, (C) 2021 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestFairQueueWriterPreferred stresses a single resource with a steady
+// stream of readers racing a writer and asserts the writer is never
+// starved: it must be admitted well before every reader queued after it.
+func TestFairQueueWriterPreferred(t *testing.T) {
+	q := &fairQueue{}
+
+	// Keep both batches comfortably under maxFairReaderBatch so every
+	// "before" reader is admitted immediately, leaving the writer as the
+	// sole front-of-line waiter once they're all active.
+	const readersBefore = 10
+	var before []*fairWaiter
+	for i := 0; i < readersBefore; i++ {
+		before = append(before, q.enqueue(PriorityInteractive, true))
+	}
+
+	writer := q.enqueue(PriorityInteractive, false)
+
+	const readersAfter = 10
+	var after []*fairWaiter
+	for i := 0; i < readersAfter; i++ {
+		after = append(after, q.enqueue(PriorityInteractive, true))
+	}
+
+	// Drain the readers queued ahead of the writer - this is the only way
+	// the writer can ever become the front of the line.
+	for _, w := range before {
+		select {
+		case <-w.ready:
+		default:
+			t.Fatalf("reader queued before the writer was never admitted")
+		}
+		q.release(w)
+	}
+
+	select {
+	case <-writer.ready:
+	default:
+		t.Fatalf("writer was not admitted once every earlier reader drained, got starved behind later waiters")
+	}
+
+	for _, w := range after {
+		select {
+		case <-w.ready:
+			t.Fatalf("waiter queued after the writer was admitted before it")
+		default:
+		}
+	}
+
+	q.release(writer)
+	for _, w := range after {
+		<-w.ready
+		q.release(w)
+	}
+}
+
+// TestFairQueuePriorityOrdering checks that, all else equal, a
+// PriorityHealing waiter queued first never jumps ahead of a
+// PriorityInteractive waiter queued afterwards.
+func TestFairQueuePriorityOrdering(t *testing.T) {
+	q := &fairQueue{}
+
+	// Hold the resource with a writer so nothing is admitted yet.
+	holder := q.enqueue(PriorityInteractive, false)
+	<-holder.ready
+
+	healing := q.enqueue(PriorityHealing, false)
+	interactive := q.enqueue(PriorityInteractive, false)
+
+	q.release(holder)
+
+	select {
+	case <-interactive.ready:
+	case <-healing.ready:
+		t.Fatalf("lower priority class (healing) was admitted ahead of interactive")
+	}
+	q.release(interactive)
+	<-healing.ready
+	q.release(healing)
+}
+
+// TestNsLockMapFairnessBoundsStarvation stress tests NsLockMap.lock with a
+// mix of readers and writers across priority classes and checks that no
+// caller waits an unbounded amount of time to acquire the lock.
+func TestNsLockMapFairnessBoundsStarvation(t *testing.T) {
+	n := NewNSLock(false)
+
+	const writers = 4
+	const readers = 16
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var maxWait time.Duration
+
+	run := func(readLock bool, priority LockPriority) {
+		defer wg.Done()
+		opsID := mustGetUUID()
+		start := UTCNow()
+		locked := n.lock(context.Background(), "bucket", "object", "test", opsID, readLock, time.Second, LockOptions{Priority: priority})
+		wait := UTCNow().Sub(start)
+		mu.Lock()
+		if wait > maxWait {
+			maxWait = wait
+		}
+		mu.Unlock()
+		if locked {
+			time.Sleep(time.Millisecond)
+			n.unlock("bucket", "object", readLock, opsID)
+		}
+	}
+
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go run(false, PriorityInteractive)
+	}
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go run(true, PriorityBackground)
+	}
+	wg.Wait()
+
+	if maxWait > 2*time.Second {
+		t.Errorf("a caller waited %v for a lock that should have been granted within its 1s timeout plus scheduling slack", maxWait)
+	}
+}
+
+// TestDetectCycleFindsCycle checks that detectCycle reports a cycle in a
+// simple A -> B -> C -> A wait-for graph.
+func TestDetectCycleFindsCycle(t *testing.T) {
+	edges := map[string][]string{
+		"A": {"B"},
+		"B": {"C"},
+		"C": {"A"},
+	}
+	cycle := detectCycle(edges)
+	if cycle == nil {
+		t.Fatal("expected a cycle to be detected, got none")
+	}
+	seen := make(map[string]bool, len(cycle))
+	for _, n := range cycle {
+		seen[n] = true
+	}
+	for _, n := range []string{"A", "B", "C"} {
+		if !seen[n] {
+			t.Errorf("cycle %v is missing node %q", cycle, n)
+		}
+	}
+}
+
+// TestDetectCycleAcyclic checks that detectCycle returns nil for a graph
+// with no cycle, including one with a shared dependency.
+func TestDetectCycleAcyclic(t *testing.T) {
+	edges := map[string][]string{
+		"A": {"C"},
+		"B": {"C"},
+	}
+	if cycle := detectCycle(edges); cycle != nil {
+		t.Fatalf("expected no cycle, got %v", cycle)
+	}
+}
+
+// TestLockGraphTrackerHolderLifecycle exercises addWaiter, promoteToHolder
+// and removeHolder and checks the tracker's bookkeeping at each step.
+func TestLockGraphTrackerHolderLifecycle(t *testing.T) {
+	g := newLockGraphTracker()
+
+	g.addWaiter("resource", "ops1", "source.go:1", nil)
+	edges := g.waitForEdges()
+	if len(edges) != 0 {
+		t.Fatalf("expected no wait-for edges before any holder exists, got %v", edges)
+	}
+
+	g.promoteToHolder("resource", "ops1", "source.go:1")
+	if !g.isHolder([]string{"resource"}, "ops1") {
+		t.Fatal("expected ops1 to be a holder of resource after promotion")
+	}
+
+	g.addWaiter("resource", "ops2", "source.go:2", nil)
+	edges = g.waitForEdges()
+	if got := edges["ops2"]; len(got) != 1 || got[0] != "ops1" {
+		t.Fatalf("expected ops2 to wait on ops1, got %v", got)
+	}
+
+	g.removeHolder("resource", "ops1")
+	if g.isHolder([]string{"resource"}, "ops1") {
+		t.Fatal("expected ops1 to no longer be a holder after removeHolder")
+	}
+
+	g.removeWaiter("resource", "ops2")
+	edges = g.waitForEdges()
+	if len(edges) != 0 {
+		t.Fatalf("expected no wait-for edges once the waiter is removed, got %v", edges)
+	}
+}
+
+// TestLockGraphTrackerDeadlocksEmptyIsNotNil checks that Deadlocks returns an
+// empty, non-nil slice when no cycle is present so it JSON-encodes to []
+// rather than null, matching the documented admin API contract.
+func TestLockGraphTrackerDeadlocksEmptyIsNotNil(t *testing.T) {
+	g := newLockGraphTracker()
+	g.addWaiter("resource", "ops1", "source.go:1", nil)
+	g.promoteToHolder("resource", "ops2", "source.go:2")
+
+	cycles := g.Deadlocks()
+	if cycles == nil {
+		t.Fatal("expected Deadlocks to return a non-nil empty slice, got nil")
+	}
+	if len(cycles) != 0 {
+		t.Fatalf("expected no deadlocks, got %v", cycles)
+	}
+}
+
+// TestAbortYoungestWaiterCancelsOnlyYoungest builds two real waiters with
+// live cancel funcs and checks that abortYoungestWaiter cancels exactly
+// the one that started waiting more recently, leaving the older waiter
+// untouched.
+func TestAbortYoungestWaiterCancelsOnlyYoungest(t *testing.T) {
+	g := newLockGraphTracker()
+
+	olderCtx, olderCancel := context.WithCancel(context.Background())
+	defer olderCancel()
+	youngerCtx, youngerCancel := context.WithCancel(context.Background())
+	defer youngerCancel()
+
+	g.addWaiter("resourceA", "older", "source.go:1", olderCancel)
+	time.Sleep(time.Millisecond)
+	g.addWaiter("resourceB", "younger", "source.go:2", youngerCancel)
+
+	g.abortYoungestWaiter([]string{"older", "younger"})
+
+	select {
+	case <-youngerCtx.Done():
+	default:
+		t.Fatal("expected the younger waiter to be aborted")
+	}
+	select {
+	case <-olderCtx.Done():
+		t.Fatal("did not expect the older waiter to be aborted")
+	default:
+	}
+}
+
+// TestDetectDeadlocksOnceBreaksRealDeadlock builds an actual A-waits-on-B,
+// B-waits-on-A deadlock through NsLockMap.lock and checks that
+// detectDeadlocksOnce unwedges it end-to-end: the youngest blocked call
+// returns instead of hanging forever, and the survivor goes on to acquire
+// both resources.
+func TestDetectDeadlocksOnceBreaksRealDeadlock(t *testing.T) {
+	n := NewNSLock(false)
+	const volume = "deadlock-test-bucket"
+
+	opsA := mustGetUUID()
+	opsB := mustGetUUID()
+
+	if !n.lock(context.Background(), volume, "x", "test", opsA, false, time.Second, LockOptions{}) {
+		t.Fatal("opsA failed to acquire x")
+	}
+	if !n.lock(context.Background(), volume, "y", "test", opsB, false, time.Second, LockOptions{}) {
+		t.Fatal("opsB failed to acquire y")
+	}
+
+	results := make(chan bool, 2)
+	go func() {
+		results <- n.lock(context.Background(), volume, "y", "test", opsA, false, 5*time.Second, LockOptions{})
+	}()
+	// Give opsA a head start queuing on y so it is unambiguously the older
+	// waiter, making opsB the youngest once it also blocks on x.
+	time.Sleep(50 * time.Millisecond)
+	go func() {
+		results <- n.lock(context.Background(), volume, "x", "test", opsB, false, 5*time.Second, LockOptions{})
+	}()
+
+	// Wait for the wait-for graph to actually show the cycle before
+	// breaking it, instead of racing detectDeadlocksOnce against the two
+	// goroutines above still queuing.
+	deadline := time.After(time.Second)
+	for {
+		if edges := globalLockGraph.waitForEdges(); detectCycle(edges) != nil {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("wait-for graph never showed the expected cycle")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	cycle := globalLockGraph.detectDeadlocksOnce()
+	if cycle == nil {
+		t.Fatal("expected detectDeadlocksOnce to find and break a cycle")
+	}
+
+	// opsA's pending lock on y cannot complete until y is released below,
+	// so whichever result arrives first must be opsB's aborted attempt.
+	first := <-results
+	if first {
+		t.Fatal("expected the aborted, youngest waiter to fail to acquire its lock")
+	}
+
+	// opsB never lost its original hold on y - only its blocked wait for x
+	// was aborted - so releasing it here is what finally lets opsA's
+	// pending acquisition of y go through.
+	n.unlock(volume, "y", false, opsB)
+
+	second := <-results
+	if !second {
+		t.Fatal("expected the surviving waiter to go on and acquire its lock")
+	}
+	n.unlock(volume, "y", false, opsA)
+	n.unlock(volume, "x", false, opsA)
+}